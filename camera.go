@@ -0,0 +1,86 @@
+package main
+
+import "image"
+
+// LerpPointTransformer returns a PointTransformer with bounds and ratio
+// linearly interpolated between a and b, where t is 0 at a and 1 at b.
+func LerpPointTransformer(a, b PointTransformer, t float64) PointTransformer {
+	return PointTransformer{
+		Bounds: image.Rectangle{
+			Min: image.Point{
+				X: lerpInt(a.Bounds.Min.X, b.Bounds.Min.X, t),
+				Y: lerpInt(a.Bounds.Min.Y, b.Bounds.Min.Y, t),
+			},
+			Max: image.Point{
+				X: lerpInt(a.Bounds.Max.X, b.Bounds.Max.X, t),
+				Y: lerpInt(a.Bounds.Max.Y, b.Bounds.Max.Y, t),
+			},
+		},
+		ratio: a.ratio + (b.ratio-a.ratio)*t,
+	}
+}
+
+func lerpInt(a, b int, t float64) int {
+	return int(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// smoothstep eases t (expected in [0, 1]) along the classic
+// 3t^2 - 2t^3 curve, so camera transitions accelerate in and decelerate
+// out instead of moving at a constant rate.
+func smoothstep(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// windowBounds returns the union of Bounds() over ticks[i : i+lookahead],
+// clamped to the slice, so a camera rebuild targets where bots are
+// heading rather than only where they are on this tick.
+func windowBounds(ticks []Tick, i, lookahead int) image.Rectangle {
+	end := i + lookahead
+	if end > len(ticks) {
+		end = len(ticks)
+	}
+
+	bounds := ticks[i].Bounds()
+	for _, tick := range ticks[i+1 : end] {
+		b := tick.Bounds()
+		if b.Min.X < bounds.Min.X {
+			bounds.Min.X = b.Min.X
+		}
+		if b.Min.Y < bounds.Min.Y {
+			bounds.Min.Y = b.Min.Y
+		}
+		if b.Max.X > bounds.Max.X {
+			bounds.Max.X = b.Max.X
+		}
+		if b.Max.Y > bounds.Max.Y {
+			bounds.Max.Y = b.Max.Y
+		}
+	}
+	return bounds
+}
+
+// cameraEase drives the eased transition between two PointTransformers
+// whenever a rebuild is triggered, instead of snapping straight to the
+// new bounds.
+type cameraEase struct {
+	from, to PointTransformer
+	frame    int
+	total    int
+}
+
+// newCameraEase starts a new transition toward to, to complete over
+// total frames.
+func newCameraEase(from, to PointTransformer, total int) cameraEase {
+	return cameraEase{from: from, to: to, frame: 0, total: total}
+}
+
+// step advances the transition by one frame and returns the
+// PointTransformer to draw this frame with.
+func (c *cameraEase) step() PointTransformer {
+	if c.frame >= c.total {
+		return c.to
+	}
+	t := smoothstep(float64(c.frame) / float64(c.total))
+	c.frame++
+	return LerpPointTransformer(c.from, c.to, t)
+}
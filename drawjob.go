@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/golang/freetype"
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// drawJob describes one frame's worth of drawing work: which tick to
+// render, at which output index, through which PointTransformer. The
+// PointTransformer has to be computed sequentially in main because of
+// the rebuild-on-overflow and camera-ease logic; everything downstream
+// of it (this struct and drawFrame) is safe to run concurrently.
+type drawJob struct {
+	index int
+	tick  Tick
+	pt    PointTransformer
+}
+
+// drawWorker pulls jobs off jobs until it's closed, rasterizes each
+// frame, stores it in drawn, and forwards its index on the index
+// channel so the encode pipeline can pick it up. Several drawWorkers
+// run at once, so a frame may finish drawing well before or after its
+// neighbors; the encode side already tolerates out-of-order arrival.
+// Each worker owns its own *freetype.Context, since a *freetype.Context
+// isn't safe to mutate from more than one goroutine at a time.
+func drawWorker(jobs <-chan drawJob, ticks []Tick, imageSize int, playerStyles map[int]PlayerStyle, hud *HUD, trailLength int) {
+	ctx := hud.newContext()
+	for job := range jobs {
+		img, err := drawFrame(ctx, job, ticks, imageSize, playerStyles, hud, trailLength)
+		if err != nil {
+			log.Fatalf("Error drawing frame %d: %v\n", job.index, err)
+		}
+		drawn[job.index] = img
+		index <- job.index
+	}
+}
+
+// drawFrame rasterizes a single tick into an *image.RGBA: grid, logo,
+// shots, explosions, trails, then bot bodies and shields, then the HUD
+// on top. It reads only job, ticks, and its other arguments, and
+// writes only to ctx (owned by the calling drawWorker), so it's safe
+// to call from multiple goroutines at once as long as each passes its
+// own ctx.
+func drawFrame(ctx *freetype.Context, job drawJob, ticks []Tick, imageSize int, playerStyles map[int]PlayerStyle, hud *HUD, trailLength int) (*image.RGBA, error) {
+	tick, pt := job.tick, job.pt
+
+	img := image.NewRGBA(image.Rect(0, 0, imageSize, imageSize))
+	gc := draw2dimg.NewGraphicContext(img)
+
+	// Draw grid lines
+	gc.SetStrokeColor(GridColor)
+	gc.SetLineWidth(1)
+	for x := pt.Bounds.Min.X; x <= pt.Bounds.Max.X; x++ {
+		if x%GridLineSpacing == 0 {
+			gc.BeginPath()
+			gc.MoveTo(pt.X(x), pt.Y(pt.Bounds.Min.Y))
+			gc.LineTo(pt.X(x), pt.Y(pt.Bounds.Max.Y))
+			gc.Stroke()
+		}
+	}
+	for y := pt.Bounds.Min.Y; y <= pt.Bounds.Max.Y; y++ {
+		if y%GridLineSpacing == 0 {
+			gc.BeginPath()
+			gc.MoveTo(pt.X(pt.Bounds.Min.X), pt.Y(y))
+			gc.LineTo(pt.X(pt.Bounds.Max.X), pt.Y(y))
+			gc.Stroke()
+		}
+	}
+
+	sx, sy := -650, -100 // Logo text starts at x=-650 with its baseline at y=-100
+	if err := drawLogo(hud, ctx, img, "SCRAPPERS", pt.X(sx), pt.Y(sy), pt.Resize(100)); err != nil {
+		return nil, err
+	}
+
+	// Draw shots
+
+	gc.SetLineWidth(1)
+	gc.SetStrokeColor(ColorRed)
+	for _, bot := range tick.Bots {
+		if bot.Fired {
+			gc.BeginPath()
+			gc.MoveTo(pt.X(bot.HitX), pt.Y(bot.HitY))
+			gc.LineTo(pt.X(bot.X), pt.Y(bot.Y))
+			gc.Stroke()
+		}
+	}
+
+	// Draw exploded bots
+
+	gc.SetFillColor(ColorExplosion)
+	for _, bot := range tick.Bots {
+		if bot.Health <= 0 {
+			gc.BeginPath()
+			circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize*2))
+			gc.Fill()
+		}
+	}
+
+	// Draw motion trails behind each living bot before its body. The
+	// trail buffer is rebuilt from the raw tick list rather than
+	// threaded through as shared state, so concurrent drawFrame calls
+	// never contend over it.
+	if trailLength > 0 {
+		trails := newTrailBufferFromTicks(ticks, job.index, trailLength)
+		for _, bot := range tick.Bots {
+			if bot.Health <= 0 {
+				continue
+			}
+			style, ok := playerStyles[bot.PID]
+			if !ok {
+				return nil, fmt.Errorf("no player style configured for PID %d", bot.PID)
+			}
+			drawTrail(gc, pt, trails, style, bot, trailLength)
+		}
+	}
+
+	// Draw bot bodies and shields
+	for _, bot := range tick.Bots {
+
+		// Skip bots that are dead. We drew an explosion for them
+		if bot.Health <= 0 {
+			continue
+		}
+
+		style, ok := playerStyles[bot.PID]
+		if !ok {
+			return nil, fmt.Errorf("no player style configured for PID %d", bot.PID)
+		}
+
+		// Determine body color
+		bodyColor := blendBodyColor(style, bot)
+
+		// Draw body
+
+		healthSize := float64(MaxBotHealth-bot.Health) / float64(MaxBotHealth)
+		gc.SetStrokeColor(ColorBlack)
+		gc.SetFillColor(bodyColor)
+		gc.SetLineWidth(1)
+
+		drawShapeAt(gc, style.Shape, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2))
+		gc.FillStroke()
+
+		if healthSize > 0 {
+			gc.SetFillColor(ColorBlack)
+			gc.SetLineWidth(0)
+			drawShapeAt(gc, style.Shape, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2)*healthSize)
+			gc.FillStroke()
+		}
+
+		// Draw shield
+		if bot.Shield {
+			gc.SetStrokeColor(style.ShieldColor)
+			gc.SetFillColor(ColorTransparent)
+			circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2)*1.1)
+			gc.FillStroke()
+		}
+	}
+
+	// Draw the HUD last so it sits on top of all bot geometry.
+	if err := hud.DrawScoreboard(ctx, img, tick, job.index, len(ticks)); err != nil {
+		return nil, err
+	}
+
+	return img, nil
+}
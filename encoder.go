@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/png"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+
+	"github.com/kettek/apng"
+)
+
+// Encoder takes drawn frames, in any completion order, and produces the
+// final output file. EncodeFrame may be called concurrently by the
+// worker pool in convert(); implementations that require frames in
+// order (APNGEncoder, MP4Encoder) buffer and flush internally.
+type Encoder interface {
+	EncodeFrame(i int, img *image.RGBA) error
+	Close() error
+}
+
+// NewEncoder builds the Encoder for the requested format. GIFEncoder and
+// APNGEncoder read the package-level delays slice only in Close, so they
+// see the first/last frame lingering adjustment main makes after the
+// draw loop.
+func NewEncoder(format, out string, imageSize, ticksPerSecond, total int) (Encoder, error) {
+	switch format {
+	case "gif":
+		return NewGIFEncoder(out, total), nil
+	case "png":
+		return NewPNGSequenceEncoder(out)
+	case "apng":
+		return NewAPNGEncoder(out, total), nil
+	case "mp4":
+		return NewMP4Encoder(out, imageSize, ticksPerSecond)
+	default:
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// GIFEncoder reproduces the renderer's original behavior: each frame is
+// dithered down to the fixed 256-color Plan9 palette with
+// Floyd-Steinberg error diffusion, then written out as an animated GIF.
+type GIFEncoder struct {
+	out       string
+	converted []*image.Paletted
+}
+
+// NewGIFEncoder preallocates room for total frames.
+func NewGIFEncoder(out string, total int) *GIFEncoder {
+	return &GIFEncoder{out: out, converted: make([]*image.Paletted, total)}
+}
+
+func (e *GIFEncoder) EncodeFrame(i int, img *image.RGBA) error {
+	pal := image.NewPaletted(img.Bounds(), palette.Plan9[:256])
+	draw.FloydSteinberg.Draw(pal, img.Bounds(), img, image.ZP)
+	e.converted[i] = pal
+	return nil
+}
+
+func (e *GIFEncoder) Close() error {
+	f, err := os.OpenFile(e.out, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gif.EncodeAll(f, &gif.GIF{
+		Image: e.converted,
+		Delay: delays,
+	})
+}
+
+// PNGSequenceEncoder writes each frame as a full-color PNG into a
+// directory, skipping palette conversion entirely so the body tints
+// and explosion alpha blend come through undegraded.
+type PNGSequenceEncoder struct {
+	dir string
+}
+
+// NewPNGSequenceEncoder creates dir (and any parents) to hold the
+// frame_%05d.png sequence.
+func NewPNGSequenceEncoder(dir string) (*PNGSequenceEncoder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &PNGSequenceEncoder{dir: dir}, nil
+}
+
+func (e *PNGSequenceEncoder) EncodeFrame(i int, img *image.RGBA) error {
+	f, err := os.Create(filepath.Join(e.dir, fmt.Sprintf("frame_%05d.png", i)))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return png.Encode(f, img)
+}
+
+func (e *PNGSequenceEncoder) Close() error {
+	return nil
+}
+
+// APNGEncoder buffers every full-color frame and flushes them, in
+// index order, as an animated PNG via github.com/kettek/apng.
+type APNGEncoder struct {
+	out    string
+	frames []*image.RGBA
+}
+
+// NewAPNGEncoder preallocates room for total frames.
+func NewAPNGEncoder(out string, total int) *APNGEncoder {
+	return &APNGEncoder{out: out, frames: make([]*image.RGBA, total)}
+}
+
+func (e *APNGEncoder) EncodeFrame(i int, img *image.RGBA) error {
+	e.frames[i] = img
+	return nil
+}
+
+func (e *APNGEncoder) Close() error {
+	f, err := os.Create(e.out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	a := apng.APNG{Frames: make([]apng.Frame, len(e.frames))}
+	for i, img := range e.frames {
+		a.Frames[i] = apng.Frame{
+			Image:            img,
+			DelayNumerator:   uint16(delays[i]),
+			DelayDenominator: 100,
+		}
+	}
+	return apng.Encode(f, a)
+}
+
+// MP4Encoder pipes raw RGBA frames to an ffmpeg child process as they
+// arrive, holding back any that land out of order until the gap is
+// filled, so the video track ends up in tick order regardless of which
+// worker finished drawing first.
+type MP4Encoder struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	pending map[int]*image.RGBA
+	next    int
+}
+
+// NewMP4Encoder starts an ffmpeg process reading raw RGBA frames of
+// imageSize x imageSize on stdin at ticksPerSecond frames per second,
+// and encodes them to out.
+func NewMP4Encoder(out string, imageSize, ticksPerSecond int) (*MP4Encoder, error) {
+	cmd := exec.Command("ffmpeg",
+		"-y",
+		"-f", "rawvideo",
+		"-pix_fmt", "rgba",
+		"-s", fmt.Sprintf("%dx%d", imageSize, imageSize),
+		"-r", fmt.Sprintf("%d", ticksPerSecond),
+		"-i", "-",
+		"-pix_fmt", "yuv420p",
+		out,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	return &MP4Encoder{cmd: cmd, stdin: stdin, pending: map[int]*image.RGBA{}}, nil
+}
+
+func (e *MP4Encoder) EncodeFrame(i int, img *image.RGBA) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.pending[i] = img
+	return e.flushLocked()
+}
+
+// flushLocked writes every contiguous frame starting at e.next, and
+// stops at the first gap. Callers must hold e.mu.
+func (e *MP4Encoder) flushLocked() error {
+	for {
+		img, ok := e.pending[e.next]
+		if !ok {
+			return nil
+		}
+		if _, err := e.stdin.Write(img.Pix); err != nil {
+			return err
+		}
+		delete(e.pending, e.next)
+		e.next++
+	}
+}
+
+func (e *MP4Encoder) Close() error {
+	if err := e.stdin.Close(); err != nil {
+		return err
+	}
+	return e.cmd.Wait()
+}
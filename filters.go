@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/gift"
+)
+
+// ParseFilters turns a comma-separated filter spec like
+// "gamma:1.2,contrast:10,blur:0.5,vignette" into a *gift.GIFT chain,
+// applied in the order given. An empty spec returns a nil chain.
+func ParseFilters(spec string) (*gift.GIFT, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	g := gift.New()
+	for _, part := range strings.Split(spec, ",") {
+		name := part
+		arg := ""
+		if idx := strings.IndexByte(part, ':'); idx >= 0 {
+			name, arg = part[:idx], part[idx+1:]
+		}
+
+		switch name {
+		case "gamma":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.Gamma(float32(f)))
+		case "contrast":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.Contrast(float32(f)))
+		case "brightness":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.Brightness(float32(f)))
+		case "saturation":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.Saturation(float32(f)))
+		case "blur":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.GaussianBlur(float32(f)))
+		case "pixelate":
+			f, err := parseFilterArg(part, arg)
+			if err != nil {
+				return nil, err
+			}
+			g.Add(gift.Pixelate(int(f)))
+		case "vignette":
+			g.Add(vignetteFilter{})
+		default:
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+	}
+	return g, nil
+}
+
+func parseFilterArg(part, arg string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(arg), 64)
+	if err != nil {
+		return 0, fmt.Errorf("filter %q: %w", part, err)
+	}
+	return f, nil
+}
+
+// ApplyFilters runs img through g and returns the result, allocating
+// the destination at whatever size g.Bounds reports (gift filters like
+// crops or rotations can change dimensions, even though none of ours
+// do today).
+func ApplyFilters(g *gift.GIFT, img *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(g.Bounds(img.Bounds()))
+	g.Draw(dst, img)
+	return dst
+}
+
+// vignetteFilter darkens an image radially from its center. It
+// satisfies gift.Filter so it can sit in the same chain as gift's
+// built-in filters.
+type vignetteFilter struct{}
+
+// vignetteStrength is how much the corners are darkened, from 1.0 (no
+// darkening) down to 1.0-vignetteStrength at the farthest corner.
+const vignetteStrength = 0.6
+
+func (vignetteFilter) Bounds(srcBounds image.Rectangle) image.Rectangle {
+	return srcBounds
+}
+
+func (vignetteFilter) Draw(dst draw.Image, src image.Image, options *gift.Options) {
+	bounds := src.Bounds()
+	cx := float64(bounds.Min.X+bounds.Max.X) / 2
+	cy := float64(bounds.Min.Y+bounds.Max.Y) / 2
+	maxDist := math.Hypot(float64(bounds.Dx())/2, float64(bounds.Dy())/2)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dist := math.Hypot(float64(x)-cx, float64(y)-cy) / maxDist
+			weight := 1 - vignetteStrength*dist*dist
+
+			r, gg, b, a := src.At(x, y).RGBA()
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(float64(r) * weight),
+				G: uint16(float64(gg) * weight),
+				B: uint16(float64(b) * weight),
+				A: uint16(a),
+			})
+		}
+	}
+}
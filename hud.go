@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"sort"
+	"sync"
+
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// HUDMode controls how much information the heads-up display shows.
+type HUDMode string
+
+const (
+	HUDOff     HUDMode = "off"
+	HUDOn      HUDMode = "on"
+	HUDMinimal HUDMode = "minimal"
+)
+
+// HUDMargin is the inset, in pixels, kept between the viewport edge and
+// any HUD text.
+const HUDMargin = 10
+
+// HUD draws the per-frame scoreboard and the SCRAPPERS logo through a
+// FreeType pipeline, so both can use an arbitrary TTF font. It holds no
+// *freetype.Context of its own: a *freetype.Context mutates its dst,
+// clip, src, and font size on every draw call, so sharing one across
+// concurrent draw workers would race. Callers get their own context
+// via newContext and thread it through the Draw* calls instead.
+type HUD struct {
+	font   *truetype.Font
+	face   font.Face
+	faceMu sync.Mutex // a font.Face is not safe for concurrent use
+	size   float64
+	mode   HUDMode
+	styles map[int]PlayerStyle
+}
+
+// shapeGlyphs is the short text glyph drawn next to each player's name
+// in the HUD legend.
+var shapeGlyphs = map[string]string{
+	"circle":   "o",
+	"hexagon":  "hex",
+	"triangle": "tri",
+	"square":   "sq",
+	"pentagon": "pent",
+	"star":     "star",
+}
+
+// NewHUD parses the font at fontPath, or the bundled default if fontPath
+// is empty, and prepares a font face sized for fontSize for measuring
+// text. Drawing contexts are created separately by newContext.
+func NewHUD(fontPath string, fontSize float64, mode HUDMode, styles map[int]PlayerStyle) (*HUD, error) {
+	fontBytes := goregular.TTF
+	if fontPath != "" {
+		b, err := ioutil.ReadFile(fontPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading font file: %w", err)
+		}
+		fontBytes = b
+	}
+
+	parsed, err := truetype.Parse(fontBytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing font: %w", err)
+	}
+
+	face := truetype.NewFace(parsed, &truetype.Options{Size: fontSize})
+
+	return &HUD{font: parsed, face: face, size: fontSize, mode: mode, styles: styles}, nil
+}
+
+// newContext returns a fresh *freetype.Context sized for the HUD's font.
+// Each draw worker calls this once and keeps the result to itself,
+// rather than every worker mutating a single shared context.
+func (h *HUD) newContext() *freetype.Context {
+	ctx := freetype.NewContext()
+	ctx.SetFont(h.font)
+	ctx.SetFontSize(h.size)
+	return ctx
+}
+
+// sortedPIDs returns the HUD's player PIDs in ascending order, for
+// stable scoreboard and legend layout.
+func (h *HUD) sortedPIDs() []int {
+	pids := make([]int, 0, len(h.styles))
+	for pid := range h.styles {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+	return pids
+}
+
+// frame points ctx at this tick's image so the following DrawText calls
+// land on it.
+func (h *HUD) frame(ctx *freetype.Context, img *image.RGBA) {
+	ctx.SetDst(img)
+	ctx.SetClip(img.Bounds())
+}
+
+// DrawText draws s at the given image-space point (not world-space, so
+// the HUD stays fixed to the viewport regardless of camera movement),
+// using the caller-owned ctx.
+func (h *HUD) DrawText(ctx *freetype.Context, img *image.RGBA, s string, x, y int, c color.RGBA) error {
+	h.frame(ctx, img)
+	ctx.SetSrc(image.NewUniform(c))
+	_, err := ctx.DrawString(s, freetype.Pt(x, y))
+	return err
+}
+
+// MeasureText returns the pixel width of s at the HUD's font size, used
+// to right-align the scoreboard.
+func (h *HUD) MeasureText(s string) int {
+	h.faceMu.Lock()
+	defer h.faceMu.Unlock()
+	return font.MeasureString(h.face, s).Round()
+}
+
+// drawLogo paths text through the FreeType pipeline at the given
+// image-space position and size, replacing the old per-letter stroke
+// drawers so the logo can be any string. It takes the destination
+// image directly rather than a *draw2dimg.GraphicContext, since
+// draw2d's graphic context has no exported way to recover the image
+// it's backed by, and it uses the caller-owned ctx rather than one
+// shared on the HUD.
+func drawLogo(hud *HUD, ctx *freetype.Context, img *image.RGBA, text string, x, y, size float64) error {
+	ctx.SetDst(img)
+	ctx.SetClip(img.Bounds())
+	ctx.SetFontSize(size)
+	ctx.SetSrc(image.NewUniform(ColorWhite))
+	_, err := ctx.DrawString(text, freetype.Pt(int(x), int(y)))
+	ctx.SetFontSize(hud.size)
+	return err
+}
+
+// DrawScoreboard renders the tick number, per-player bot counts, total
+// scrap, and aggregate health in the top-left corner, plus a player
+// legend (shape -> PID -> name) right-aligned in the top-right corner.
+// In HUDMinimal mode only the tick number is drawn. ctx is caller-owned
+// so concurrent draw workers each use their own.
+func (h *HUD) DrawScoreboard(ctx *freetype.Context, img *image.RGBA, tick Tick, index, total int) error {
+	if h.mode == HUDOff {
+		return nil
+	}
+
+	line := fmt.Sprintf("Tick %d/%d", index+1, total)
+	if err := h.DrawText(ctx, img, line, HUDMargin, HUDMargin+int(h.size), ColorWhite); err != nil {
+		return err
+	}
+	if h.mode == HUDMinimal {
+		return nil
+	}
+
+	counts := map[int]int{}
+	health := map[int]int{}
+	var scrap uint
+	for _, bot := range tick.Bots {
+		if bot.Health > 0 {
+			counts[bot.PID]++
+			health[bot.PID] += bot.Health
+		}
+		scrap += bot.Scrap
+	}
+
+	pids := h.sortedPIDs()
+
+	y := HUDMargin + int(h.size)*2
+	for _, pid := range pids {
+		line := fmt.Sprintf("P%d bots: %d  hp: %d", pid, counts[pid], health[pid])
+		if err := h.DrawText(ctx, img, line, HUDMargin, y, ColorWhite); err != nil {
+			return err
+		}
+		y += int(h.size) + 4
+	}
+
+	scrapLine := fmt.Sprintf("Scrap: %d", scrap)
+	if err := h.DrawText(ctx, img, scrapLine, HUDMargin, y, ColorWhite); err != nil {
+		return err
+	}
+
+	// Legend, right-aligned.
+	y = HUDMargin + int(h.size)
+	for _, pid := range pids {
+		style := h.styles[pid]
+		line := fmt.Sprintf("%s P%d %s", shapeGlyphs[style.Shape], pid, style.Name)
+		w := h.MeasureText(line)
+		if err := h.DrawText(ctx, img, line, img.Bounds().Dx()-HUDMargin-w, y, style.Color); err != nil {
+			return err
+		}
+		y += int(h.size) + 4
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"sort"
+)
+
+// PlayerStyle describes how a given PID's bots are rendered: which body
+// shape to path, the base color their FPow/MPow/SPow tint is blended
+// against, a display name for the HUD legend, and the shield outline
+// color.
+type PlayerStyle struct {
+	PID         int        `json:"pid"`
+	Shape       string     `json:"shape"` // circle, hexagon, triangle, square, pentagon, star
+	Color       color.RGBA `json:"color"`
+	Name        string     `json:"name"`
+	ShieldColor color.RGBA `json:"shieldColor"`
+}
+
+// defaultShapes is the rotation of body shapes assigned to
+// auto-discovered players, in ascending PID order.
+var defaultShapes = []string{"circle", "hexagon", "triangle", "square", "pentagon", "star"}
+
+// defaultColors is the rotation of base colors assigned alongside
+// defaultShapes, chosen to stay visually distinct from one another.
+var defaultColors = []color.RGBA{
+	{0xff, 0x40, 0x40, 0xff}, // red
+	{0x40, 0x80, 0xff, 0xff}, // blue
+	{0x40, 0xff, 0x80, 0xff}, // green
+	{0xff, 0xd0, 0x40, 0xff}, // yellow
+	{0xc0, 0x40, 0xff, 0xff}, // purple
+	{0x40, 0xff, 0xff, 0xff}, // cyan
+}
+
+// DiscoverPlayerStyles scans every tick for distinct PIDs and assigns
+// each one a default shape and color, in PID order. Used when no
+// -players config file is given.
+func DiscoverPlayerStyles(ticks []Tick) map[int]PlayerStyle {
+	seen := map[int]bool{}
+	for _, tick := range ticks {
+		for _, bot := range tick.Bots {
+			seen[bot.PID] = true
+		}
+	}
+
+	pids := make([]int, 0, len(seen))
+	for pid := range seen {
+		pids = append(pids, pid)
+	}
+	sort.Ints(pids)
+
+	styles := map[int]PlayerStyle{}
+	for i, pid := range pids {
+		styles[pid] = PlayerStyle{
+			PID:         pid,
+			Shape:       defaultShapes[i%len(defaultShapes)],
+			Color:       defaultColors[i%len(defaultColors)],
+			Name:        fmt.Sprintf("Player %d", pid),
+			ShieldColor: ColorWhite,
+		}
+	}
+	return styles
+}
+
+// LoadPlayerStyles reads a players.json config file (a JSON array of
+// PlayerStyle) for callers that want explicit control over shape,
+// color, and name instead of relying on auto-discovery.
+func LoadPlayerStyles(path string) (map[int]PlayerStyle, error) {
+	dat, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading players file: %w", err)
+	}
+
+	var list []PlayerStyle
+	if err := json.Unmarshal(dat, &list); err != nil {
+		return nil, fmt.Errorf("parsing players file: %w", err)
+	}
+
+	styles := map[int]PlayerStyle{}
+	for _, s := range list {
+		styles[s.PID] = s
+	}
+	return styles, nil
+}
+
+// blendBodyColor tints bot's FPow/MPow/SPow (via PowerColorWeight) onto
+// the player's base color, one stat per channel, rather than mapping
+// the stats directly to raw RGB as before. This keeps 3+ players
+// visually distinct even as their power levels change.
+func blendBodyColor(style PlayerStyle, bot Bot) color.RGBA {
+	tint := func(base uint8, pow int) uint8 {
+		boost := uint8(PowerColorWeight * pow)
+		return uint8((uint16(base) + uint16(boost)) / 2)
+	}
+	return color.RGBA{
+		tint(style.Color.R, bot.FPow),
+		tint(style.Color.G, bot.MPow),
+		tint(style.Color.B, bot.SPow),
+		0xff,
+	}
+}
@@ -5,14 +5,13 @@ import (
 	"flag"
 	"image"
 	"image/color"
-	"image/color/palette"
-	"image/draw"
-	"image/gif"
 	"io/ioutil"
 	"log"
 	"math"
-	"os"
+	"sync"
+	"sync/atomic"
 
+	"github.com/disintegration/gift"
 	"github.com/llgcode/draw2d/draw2dimg"
 )
 
@@ -35,17 +34,23 @@ const (
 )
 
 var (
-	// Images waiting to be converted.
+	// Images waiting to be encoded.
 	drawn []*image.RGBA
-	// Converted images.
-	converted []*image.Paletted
-	// Delays between frames.
+	// The encoder frames are handed off to once drawn.
+	encoder Encoder
+	// The post-processing filter chain applied before encoding, or nil.
+	filters *gift.GIFT
+	// Delays between frames, in 1/100ths of a second.
 	delays []int
 	// What index we're on for converting
 	index chan int
 	// When we're done with things
 	drawDone    chan bool
 	convertDone chan bool
+	// Total number of frames being rendered, and how many have actually
+	// been encoded so far, for progress reporting in convert.
+	totalFrames int
+	framesDone  int64
 	// Constant colors
 	ColorBlack       color.RGBA = color.RGBA{0x00, 0x00, 0x00, 0xff}
 	ColorWhite       color.RGBA = color.RGBA{0xff, 0xff, 0xff, 0xff}
@@ -170,10 +175,19 @@ func main() {
 
 	// Read cli options
 	var inFile = flag.String("in", "scrappers.json", "Specify the name of the JSON game file to be rendered.")
-	var outFile = flag.String("out", "scrappers.gif", "Specify the name of the GIF to create.")
+	var outFile = flag.String("out", "scrappers.gif", "Specify the output file to create (or directory, for -format png).")
 	var ticksPerSecond = flag.Int("speed", 12, "Specify the GIF speed in ticks per second.")
 	var threads = flag.Int("threads", 8, "Specify the number of virtual threads to use while rendering.")
 	var imageSize = flag.Int("size", 600, "Specify the dimensions of the square output image.")
+	var fontPath = flag.String("font", "", "Specify a TTF font to use for the HUD and logo. Falls back to a bundled default.")
+	var fontSize = flag.Float64("font-size", 14, "Specify the HUD font size, in points.")
+	var hudMode = flag.String("hud", "on", "Specify the HUD mode: on, off, or minimal.")
+	var playersPath = flag.String("players", "", "Specify a players.json file describing each PID's shape, color, and name. Defaults to auto-discovery.")
+	var format = flag.String("format", "gif", "Specify the output format: gif, png, apng, or mp4.")
+	var cameraEaseFrames = flag.Int("camera-ease", 8, "Specify how many frames a camera rebuild eases over.")
+	var cameraLookahead = flag.Int("camera-lookahead", 30, "Specify how many upcoming ticks the camera considers when rebuilding, so it anticipates bot movement.")
+	var trailLength = flag.Int("trail", 6, "Specify how many previous ticks of motion trail to draw behind each bot. 0 disables trails.")
+	var filterSpec = flag.String("filters", "", "Specify a comma-separated post-processing filter chain, e.g. gamma:1.2,contrast:10,blur:0.5,vignette.")
 	flag.Parse()
 
 	// Input validation
@@ -186,6 +200,28 @@ func main() {
 	if *imageSize < 1 {
 		log.Fatalln("Image size must be greater than zero.")
 	}
+	mode := HUDMode(*hudMode)
+	if mode != HUDOn && mode != HUDOff && mode != HUDMinimal {
+		log.Fatalln("HUD mode must be one of: on, off, minimal.")
+	}
+	switch *format {
+	case "gif", "png", "apng", "mp4":
+	default:
+		log.Fatalln("Format must be one of: gif, png, apng, mp4.")
+	}
+	if *cameraEaseFrames < 1 {
+		log.Fatalln("Camera ease must be greater than zero.")
+	}
+	if *cameraLookahead < 1 {
+		log.Fatalln("Camera lookahead must be greater than zero.")
+	}
+	if *trailLength < 0 {
+		log.Fatalln("Trail must be zero or greater.")
+	}
+	filters, err = ParseFilters(*filterSpec)
+	if err != nil {
+		log.Fatalf("Error parsing filters: %v\n", err)
+	}
 
 	// Load data file
 	dat, err := ioutil.ReadFile(*inFile)
@@ -201,12 +237,32 @@ func main() {
 	}
 	log.Printf("Processing %v ticks.\n", len(ticks))
 
+	var playerStyles map[int]PlayerStyle
+	if *playersPath != "" {
+		playerStyles, err = LoadPlayerStyles(*playersPath)
+		if err != nil {
+			log.Fatalf("Error loading players file: %v\n", err)
+		}
+	} else {
+		playerStyles = DiscoverPlayerStyles(ticks)
+	}
+
+	hud, err := NewHUD(*fontPath, *fontSize, mode, playerStyles)
+	if err != nil {
+		log.Fatalf("Error loading HUD font: %v\n", err)
+	}
+
 	drawn = make([]*image.RGBA, len(ticks))
-	converted = make([]*image.Paletted, len(ticks))
 	delays = make([]int, len(ticks))
 	drawDone = make(chan bool)
 	convertDone = make(chan bool)
 	index = make(chan int)
+	totalFrames = len(ticks)
+
+	encoder, err = NewEncoder(*format, *outFile, *imageSize, *ticksPerSecond, len(ticks))
+	if err != nil {
+		log.Fatalf("Error setting up %s encoder: %v\n", *format, err)
+	}
 
 	// Start rendering
 	for n := 1; n <= *threads; n++ {
@@ -218,17 +274,37 @@ func main() {
 	tickBounds := ticks[0].Bounds()
 	pt := NewPointTransformer(tickBounds, Padding, *imageSize)
 
+	// ease drives the current camera rebuild transition, if any. It
+	// starts fully settled (frame == total) so the first tick's
+	// PointTransformer is used as-is.
+	ease := newCameraEase(pt, pt, *cameraEaseFrames)
+	ease.frame = ease.total
+
+	// Draw workers turn dispatched drawJobs into *image.RGBA frames and
+	// forward each index on to the encode pipeline. Only the
+	// PointTransformer computation below has to stay sequential; actual
+	// rasterization is the expensive part and can run concurrently.
+	jobs := make(chan drawJob)
+	var drawWG sync.WaitGroup
+	for n := 1; n <= *threads; n++ {
+		drawWG.Add(1)
+		go func() {
+			defer drawWG.Done()
+			drawWorker(jobs, ticks, *imageSize, playerStyles, hud, *trailLength)
+		}()
+	}
+
 	for i, tick := range ticks {
 
 		// If the bounds of this tick fall outside of the bounds
-		// of our point transformer, rebuild the point transformer.
+		// of our point transformer, rebuild the point transformer,
+		// easing toward it instead of snapping so the camera doesn't
+		// jump-cut.
 		tickBounds = tick.Bounds()
-		if tickBounds.Min.Y-BotSize < pt.Bounds.Min.Y ||
+		rebuild := tickBounds.Min.Y-BotSize < pt.Bounds.Min.Y ||
 			tickBounds.Min.X-BotSize < pt.Bounds.Min.X ||
 			tickBounds.Max.Y+BotSize > pt.Bounds.Max.Y ||
-			tickBounds.Max.X+BotSize > pt.Bounds.Max.X {
-			pt = NewPointTransformer(tickBounds, Padding, *imageSize)
-		}
+			tickBounds.Max.X+BotSize > pt.Bounds.Max.X
 
 		// If the bounds of this tick are ShrinkPercent or less of the bounds
 		// of the point transformer, rebuild point transformer.
@@ -237,169 +313,23 @@ func main() {
 		ptYSize := pt.Bounds.Max.Y - pt.Bounds.Min.Y
 		ptXSize := pt.Bounds.Max.X - pt.Bounds.Min.X
 		if thisYSize*100/ptYSize <= ShrinkPercent && thisXSize*100/ptXSize <= ShrinkPercent {
-			pt = NewPointTransformer(tickBounds, Padding, *imageSize)
-		}
-
-		// Initialize a new image
-		img := image.NewRGBA(image.Rect(0, 0, *imageSize, *imageSize))
-		gc := draw2dimg.NewGraphicContext(img)
-
-		// Draw grid lines
-		gc.SetStrokeColor(GridColor)
-		gc.SetLineWidth(1)
-		for x := pt.Bounds.Min.X; x <= pt.Bounds.Max.X; x++ {
-			if x%GridLineSpacing == 0 {
-				gc.BeginPath()
-				gc.MoveTo(pt.X(x), pt.Y(pt.Bounds.Min.Y))
-				gc.LineTo(pt.X(x), pt.Y(pt.Bounds.Max.Y))
-				gc.Stroke()
-			}
-		}
-		for y := pt.Bounds.Min.Y; y <= pt.Bounds.Max.Y; y++ {
-			if y%GridLineSpacing == 0 {
-				gc.BeginPath()
-				gc.MoveTo(pt.X(pt.Bounds.Min.X), pt.Y(y))
-				gc.LineTo(pt.X(pt.Bounds.Max.X), pt.Y(y))
-				gc.Stroke()
-			}
-		}
-
-		sx, sy := -650, -100 // Logo centered at 0,0
-		gc.SetLineWidth(pt.Resize(24))
-		// S
-		gc.BeginPath()
-		sAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// C
-		sx += 150
-		gc.BeginPath()
-		cAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// R
-		sx += 150
-		gc.BeginPath()
-		rAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// A
-		sx += 150
-		gc.BeginPath()
-		aAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// P
-		sx += 150
-		gc.BeginPath()
-		pAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// P
-		sx += 150
-		gc.BeginPath()
-		pAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// E
-		sx += 150
-		gc.BeginPath()
-		eAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// R
-		sx += 150
-		gc.BeginPath()
-		rAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-		// S
-		sx += 150
-		gc.BeginPath()
-		sAt(gc, pt.X(sx), pt.Y(sy), pt.Resize(100))
-		gc.Stroke()
-
-		// Draw shots
-
-		gc.SetLineWidth(1)
-		gc.SetStrokeColor(ColorRed)
-		for _, bot := range tick.Bots {
-			if bot.Fired {
-				gc.BeginPath()
-				gc.MoveTo(pt.X(bot.HitX), pt.Y(bot.HitY))
-				gc.LineTo(pt.X(bot.X), pt.Y(bot.Y))
-				gc.Stroke()
-			}
+			rebuild = true
 		}
 
-		// Draw exploded bots
-
-		gc.SetFillColor(ColorExplosion)
-		for _, bot := range tick.Bots {
-			if bot.Health <= 0 {
-				gc.BeginPath()
-				circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize*2))
-				gc.Fill()
-			}
+		// A transition already under way runs to its end before another
+		// rebuild starts; restarting it every tick would pin the camera
+		// at its starting point.
+		if rebuild && ease.frame >= ease.total {
+			target := NewPointTransformer(windowBounds(ticks, i, *cameraLookahead), Padding, *imageSize)
+			ease = newCameraEase(pt, target, *cameraEaseFrames)
 		}
+		pt = ease.step()
 
-		// Draw bot bodies and shields
-		for _, bot := range tick.Bots {
-
-			// Skip bots that are dead. We drew an explosion for them
-			if bot.Health <= 0 {
-				continue
-			}
-
-			// Determine body color
-			bodyColor := color.RGBA{
-				uint8(PowerColorWeight * bot.FPow),
-				uint8(PowerColorWeight * bot.MPow),
-				uint8(PowerColorWeight * bot.SPow),
-				0xff,
-			}
-
-			// Draw body
-
-			healthSize := float64(MaxBotHealth-bot.Health) / float64(MaxBotHealth)
-			gc.SetStrokeColor(ColorBlack)
-			gc.SetFillColor(bodyColor)
-			gc.SetLineWidth(1)
-
-			if bot.PID == 1 { // Draw circles
-
-				circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2))
-				gc.FillStroke()
-
-				if healthSize > 0 {
-					gc.SetFillColor(ColorBlack)
-					gc.SetLineWidth(0)
-					circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2)*healthSize)
-					gc.FillStroke()
-				}
-
-			} else if bot.PID == 2 { // Draw hexagons
-
-				hexagonAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2))
-				gc.FillStroke()
-
-				if healthSize > 0 {
-					gc.SetFillColor(ColorBlack)
-					gc.SetLineWidth(0)
-					hexagonAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2)*healthSize)
-					gc.FillStroke()
-				}
-
-			} else {
-				log.Fatalf("This program does not support more than two players.\n")
-			}
-
-			// Draw shield
-			if bot.Shield {
-				gc.SetStrokeColor(ColorWhite)
-				gc.SetFillColor(ColorTransparent)
-				circleAt(gc, pt.X(bot.X), pt.Y(bot.Y), pt.Resize(BotSize/2)*1.1)
-				gc.FillStroke()
-			}
-		}
-
-		drawn[i] = img
 		delays[i] = 100 / *ticksPerSecond
-		index <- i
-		log.Printf("%.1f%%", float64(i*100)/float64(len(ticks)))
+		jobs <- drawJob{index: i, tick: tick, pt: pt}
 	}
+	close(jobs)
+	drawWG.Wait()
 
 	// Let first and last frame linger
 	delays[0] = 100 / 100
@@ -416,23 +346,20 @@ func main() {
 	}
 
 	// Write to disk
-	log.Println("Writing image file.")
-	f, _ := os.OpenFile(*outFile, os.O_WRONLY|os.O_CREATE, 0600)
-	defer f.Close()
-	err = gif.EncodeAll(f, &gif.GIF{
-		Image: converted,
-		Delay: delays,
-	})
-	if err != nil {
+	log.Println("Writing output file.")
+	if err := encoder.Close(); err != nil {
 		log.Fatalf("Error writing file: %v\n", err)
 	}
 
 	log.Println("Done!")
 }
 
-// Convert waits for an index of a RGBA image, then takes that image
-// off the staging array and converts it to Paletted image. Or exists
-// when the drawDone signal is sent.
+// Convert waits for an index of a RGBA image, then hands that image off
+// to the selected encoder and frees it from the staging array. Exits
+// when the drawDone signal is sent. Progress is logged here, once a
+// frame is actually encoded, rather than when it's merely dispatched to
+// a draw worker, since drawing and encoding now happen concurrently
+// with dispatch.
 func convert() {
 	for {
 		select {
@@ -441,10 +368,16 @@ func convert() {
 			return
 		case i := <-index:
 			img := drawn[i]
-			pal := image.NewPaletted(img.Bounds(), palette.Plan9[:256])
-			draw.FloydSteinberg.Draw(pal, img.Bounds(), img, image.ZP)
-			converted[i] = pal
+			if filters != nil {
+				img = ApplyFilters(filters, img)
+			}
+			if err := encoder.EncodeFrame(i, img); err != nil {
+				log.Fatalf("Error encoding frame %d: %v\n", i, err)
+			}
 			drawn[i] = nil
+
+			done := atomic.AddInt64(&framesDone, 1)
+			log.Printf("%.1f%%", float64(done*100)/float64(totalFrames))
 		}
 	}
 }
@@ -493,85 +426,6 @@ func circleAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
 // HexagonAt paths a hexagon to the graphic context
 // at the given location and size.
 func hexagonAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
-
-	sides := 6
-
-	// Calculate where edges meet
-	points := make([]Point, 0, sides)
-	for s := 1; s <= sides; s++ {
-		point := Point{
-			x + math.Cos(Radians360*(float64(s)/float64(sides)))*radius,
-			y + math.Sin(Radians360*(float64(s)/float64(sides)))*radius,
-		}
-		points = append(points, point)
-	}
-
-	// Draw hexagon
-	gc.MoveTo(points[0].X, points[0].Y)
-	for s := 1; s < sides; s++ {
-		gc.LineTo(points[s].X, points[s].Y)
-	}
-	gc.Close()
-}
-
-//////////////////////////////
-// LETTER DRAWING FUNCTIONS //
-//////////////////////////////
-
-// SAt draws an S to the graphic context at the given position and size.
-func sAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx + unit), (sy))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx), (sy + unit))
-	gc.LineTo((sx + unit), (sy + unit))
-	gc.LineTo((sx + unit), (sy + unit*2))
-	gc.LineTo((sx), (sy + unit*2))
+	polygonAt(gc, x, y, radius, 6)
 }
 
-// CAt draws a C to the graphic context at the given position and size.
-func cAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx + unit), (sy))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx), (sy + unit*2))
-	gc.LineTo((sx + unit), (sy + unit*2))
-}
-
-// RAt draws an R to the graphic context at the given position and size.
-func rAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx), (sy + unit*2))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx + unit), (sy))
-	gc.LineTo((sx + unit), (sy + unit))
-	gc.LineTo((sx), (sy + unit))
-	gc.MoveTo((sx + unit/2), (sy + unit))
-	gc.LineTo((sx + unit), (sy + unit*2))
-}
-
-// AAt draws an A to the graphic context at the given position and size.
-func aAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx), (sy + unit*2))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx + unit), (sy))
-	gc.LineTo((sx + unit), (sy + unit*2))
-	gc.MoveTo((sx), (sy + unit))
-	gc.LineTo((sx + unit), (sy + unit))
-}
-
-// PAt draws a P to the graphic context at the given position and size.
-func pAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx), (sy + unit*2))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx + unit), (sy))
-	gc.LineTo((sx + unit), (sy + unit))
-	gc.LineTo((sx), (sy + unit))
-}
-
-// EAt draws an E to the graphic context at the given position and size.
-func eAt(gc *draw2dimg.GraphicContext, sx, sy, unit float64) {
-	gc.MoveTo((sx + unit), (sy))
-	gc.LineTo((sx), (sy))
-	gc.LineTo((sx), (sy + unit*2))
-	gc.LineTo((sx + unit), (sy + unit*2))
-	gc.MoveTo((sx), (sy + unit))
-	gc.LineTo((sx + unit), (sy + unit))
-}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/llgcode/draw2d/draw2dimg"
+)
+
+// polygonAt paths a regular N-sided polygon to the graphic context at
+// the given location and size. This is the shared math hexagonAt used
+// before it was parametrized; triangleAt, squareAt, and pentagonAt all
+// go through it too.
+func polygonAt(gc *draw2dimg.GraphicContext, x, y, radius float64, sides int) {
+	points := make([]Point, 0, sides)
+	for s := 1; s <= sides; s++ {
+		point := Point{
+			x + math.Cos(Radians360*(float64(s)/float64(sides)))*radius,
+			y + math.Sin(Radians360*(float64(s)/float64(sides)))*radius,
+		}
+		points = append(points, point)
+	}
+
+	gc.MoveTo(points[0].X, points[0].Y)
+	for s := 1; s < sides; s++ {
+		gc.LineTo(points[s].X, points[s].Y)
+	}
+	gc.Close()
+}
+
+// TriangleAt paths an equilateral triangle to the graphic context at
+// the given location and size.
+func triangleAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
+	polygonAt(gc, x, y, radius, 3)
+}
+
+// SquareAt paths a square to the graphic context at the given location
+// and size.
+func squareAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
+	polygonAt(gc, x, y, radius, 4)
+}
+
+// PentagonAt paths a pentagon to the graphic context at the given
+// location and size.
+func pentagonAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
+	polygonAt(gc, x, y, radius, 5)
+}
+
+// starPoints is the number of points on the star shape starAt draws.
+const starPoints = 5
+
+// StarAt paths a five-pointed star to the graphic context, alternating
+// between radius for the outer points and 40% of radius for the
+// valleys between them.
+func starAt(gc *draw2dimg.GraphicContext, x, y, radius float64) {
+	innerRadius := radius * 0.4
+	sides := starPoints * 2
+
+	points := make([]Point, 0, sides)
+	for s := 0; s < sides; s++ {
+		r := radius
+		if s%2 == 1 {
+			r = innerRadius
+		}
+		angle := Radians360*(float64(s)/float64(sides)) - math.Pi/2
+		points = append(points, Point{
+			x + math.Cos(angle)*r,
+			y + math.Sin(angle)*r,
+		})
+	}
+
+	gc.MoveTo(points[0].X, points[0].Y)
+	for s := 1; s < sides; s++ {
+		gc.LineTo(points[s].X, points[s].Y)
+	}
+	gc.Close()
+}
+
+// drawShapeAt paths the body shape named by shape to the graphic
+// context, falling back to a circle for unrecognized names.
+func drawShapeAt(gc *draw2dimg.GraphicContext, shape string, x, y, radius float64) {
+	switch shape {
+	case "hexagon":
+		hexagonAt(gc, x, y, radius)
+	case "triangle":
+		triangleAt(gc, x, y, radius)
+	case "square":
+		squareAt(gc, x, y, radius)
+	case "pentagon":
+		pentagonAt(gc, x, y, radius)
+	case "star":
+		starAt(gc, x, y, radius)
+	default:
+		circleAt(gc, x, y, radius)
+	}
+}
+
+// fillShapeAt paths and fills shape in a single call with c, including
+// c's alpha. Callers like trail rendering need per-call alpha rather
+// than the gc.SetFillColor state the rest of the renderer relies on.
+func fillShapeAt(gc *draw2dimg.GraphicContext, shape string, x, y, radius float64, c color.RGBA) {
+	gc.BeginPath()
+	drawShapeAt(gc, shape, x, y, radius)
+	gc.SetFillColor(c)
+	gc.Fill()
+}
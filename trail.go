@@ -0,0 +1,97 @@
+package main
+
+import "github.com/llgcode/draw2d/draw2dimg"
+
+// botKey identifies a bot across ticks, for trail and previousBot
+// lookups keyed by (PID, BID).
+type botKey struct {
+	PID, BID int
+}
+
+// trailBuffer is a ring buffer of the last N ticks' bots, indexed by
+// (PID, BID), used to draw motion trails and directional vectors.
+type trailBuffer struct {
+	history []map[botKey]Bot // history[0] is the most recently pushed tick
+	size    int
+}
+
+// newTrailBuffer creates a buffer that retains the last size ticks.
+func newTrailBuffer(size int) *trailBuffer {
+	return &trailBuffer{size: size}
+}
+
+// push records tick's bots as the newest entry, evicting the oldest
+// once the buffer holds more than size ticks.
+func (t *trailBuffer) push(tick Tick) {
+	snapshot := make(map[botKey]Bot, len(tick.Bots))
+	for _, bot := range tick.Bots {
+		snapshot[botKey{bot.PID, bot.BID}] = bot
+	}
+	t.history = append([]map[botKey]Bot{snapshot}, t.history...)
+	if len(t.history) > t.size {
+		t.history = t.history[:t.size]
+	}
+}
+
+// newTrailBufferFromTicks builds a trailBuffer holding up to size ticks
+// immediately preceding ticks[i], read directly from the full tick
+// list. This lets each frame's trail be computed independently by a
+// draw worker, instead of relying on a shared, push-order-dependent
+// ring buffer.
+func newTrailBufferFromTicks(ticks []Tick, i, size int) *trailBuffer {
+	t := newTrailBuffer(size)
+	start := i - size
+	if start < 0 {
+		start = 0
+	}
+	for k := start; k < i; k++ {
+		t.push(ticks[k])
+	}
+	return t
+}
+
+// previousBot returns the bot identified by (pid, bid) as it was k
+// ticks ago (k=1 is the tick immediately before the current one), or
+// false if the buffer doesn't go back that far or the bot didn't exist
+// then.
+func (t *trailBuffer) previousBot(pid, bid, k int) (Bot, bool) {
+	if k < 1 || k > len(t.history) {
+		return Bot{}, false
+	}
+	bot, ok := t.history[k-1][botKey{pid, bid}]
+	return bot, ok
+}
+
+// drawTrail paints bot's fading historical bodies over the last n
+// ticks of t, plus a thin directional vector from its previous
+// position to its current one.
+func drawTrail(gc *draw2dimg.GraphicContext, pt PointTransformer, t *trailBuffer, style PlayerStyle, bot Bot, n int) {
+	for k := n; k >= 1; k-- {
+		prev, ok := t.previousBot(bot.PID, bot.BID, k)
+		if !ok || prev.Health <= 0 {
+			continue
+		}
+
+		age := float64(n-k) / float64(n)
+		c := blendBodyColor(style, prev)
+		c.A = uint8(age * 0.5 * 255)
+		radius := pt.Resize(BotSize/2) * (0.4 + 0.6*age)
+
+		fillShapeAt(gc, style.Shape, pt.X(prev.X), pt.Y(prev.Y), radius, c)
+	}
+
+	prev, ok := t.previousBot(bot.PID, bot.BID, 1)
+	if !ok || prev.Health <= 0 {
+		return
+	}
+
+	lineAlpha := 0.3
+	lineColor := blendBodyColor(style, bot)
+	lineColor.A = uint8(lineAlpha * 255.0)
+	gc.SetStrokeColor(lineColor)
+	gc.SetLineWidth(1)
+	gc.BeginPath()
+	gc.MoveTo(pt.X(prev.X), pt.Y(prev.Y))
+	gc.LineTo(pt.X(bot.X), pt.Y(bot.Y))
+	gc.Stroke()
+}